@@ -16,57 +16,40 @@
 package etcdserver
 
 import (
+	"bytes"
+	"fmt"
+	"reflect"
 	"runtime"
 	"strings"
 	"sync"
 	"testing"
 
 	fuzz "github.com/AdaLogics/go-fuzz-headers"
+	"github.com/coreos/go-semver/semver"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/proto"
 
 	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
 	"go.etcd.io/etcd/client/pkg/v3/types"
+	"go.etcd.io/etcd/pkg/v3/traceutil"
 	"go.etcd.io/etcd/pkg/v3/wait"
 	"go.etcd.io/etcd/raft/v3/raftpb"
+	"go.etcd.io/etcd/server/v3/auth"
 	"go.etcd.io/etcd/server/v3/etcdserver/api/membership"
 	"go.etcd.io/etcd/server/v3/etcdserver/api/v2store"
 	"go.etcd.io/etcd/server/v3/etcdserver/cindex"
+	v2pb "go.etcd.io/etcd/server/v3/etcdserver/etcdserverpb"
+	"go.etcd.io/etcd/server/v3/lease"
 	serverstorage "go.etcd.io/etcd/server/v3/storage"
+	"go.etcd.io/etcd/server/v3/storage/backend"
 	betesting "go.etcd.io/etcd/server/v3/storage/backend/testing"
 	"go.etcd.io/etcd/server/v3/storage/schema"
 )
 
-var (
-	ab applierV3
-)
-
 func init() {
 	testing.Init()
-	t := &testing.T{}
-	lg := zaptest.NewLogger(t)
-
-	cl := membership.NewCluster(zaptest.NewLogger(t))
-	cl.SetStore(v2store.New())
-	cl.AddMember(&membership.Member{ID: types.ID(1)}, true)
-
-	be, _ := betesting.NewDefaultTmpBackend(t)
-	defer betesting.Close(t, be)
-
-	schema.CreateMetaBucket(be.BatchTx())
-
-	ci := cindex.NewConsistentIndex(be)
-	srv := &EtcdServer{
-		lgMu:         new(sync.RWMutex),
-		lg:           lg,
-		id:           1,
-		r:            *realisticRaftNode(lg),
-		cluster:      cl,
-		w:            wait.New(),
-		consistIndex: ci,
-		beHooks:      serverstorage.NewBackendHooks(lg, ci),
-	}
-	srv.applyV3Internal = srv.newApplierV3Internal()
-	ab = srv.newApplierV3Backend()
 }
 
 // Fuzzapply runs into panics that should not happen in production
@@ -172,21 +155,148 @@ func catchPanics2() {
 	}
 }
 
+// FuzzapplierV3backendApply is a differential fuzzer: it applies the same
+// InternalRaftRequest to two independently constructed EtcdServers, one
+// going through the bare newApplierV3Backend() and the other through
+// newApplierV3() (the real apply path, which chains the auth/quota
+// wrappers around its own newApplierV3Backend()), and diffs the
+// resulting applyResult plus the backend key/value state. Any divergence
+// outside the whitelisted semver/downgrade parse errors caught by
+// catchPanics2 means the two applier stacks disagree on the same
+// request, which single-applier fuzzing can't see.
 func FuzzapplierV3backendApply(data []byte) int {
 	defer catchPanics2()
 	f := fuzz.NewConsumer(data)
 	rr := &pb.InternalRaftRequest{}
-	err := f.GenerateStruct(rr)
-	if err != nil {
+	if err := f.GenerateStruct(rr); err != nil {
 		return 0
 	}
 	if !shouldPass(rr, f) {
 		return 0
 	}
-	_ = ab.Apply(rr, true)
+
+	t := &testing.T{}
+	lg := zaptest.NewLogger(t)
+
+	// abBackend is the bare backend applier with no wrapping.
+	srvBackend, beBackend := newFuzzEtcdServer(t, lg)
+	defer betesting.Close(t, beBackend)
+	abBackend := srvBackend.newApplierV3Backend()
+
+	// abWrapped is the real apply path: newApplierV3() chains the
+	// auth/quota wrappers around its own newApplierV3Backend().
+	srvWrapped, beWrapped := newFuzzEtcdServer(t, lg)
+	defer betesting.Close(t, beWrapped)
+	abWrapped := srvWrapped.newApplierV3()
+
+	resBackend := abBackend.Apply(rr, true)
+	resWrapped := abWrapped.Apply(rr, true)
+
+	if !applyResultsEqual(resBackend, resWrapped) {
+		panic("newApplierV3Backend() and newApplierV3() returned different applyResults for the same request")
+	}
+	if !backendStatesEqual(beBackend, beWrapped) {
+		panic("newApplierV3Backend() and newApplierV3() left different backend state for the same request")
+	}
 	return 1
 }
 
+// newFuzzEtcdServer builds a minimal EtcdServer over a fresh tmp backend
+// and membership cluster, suitable for differential applier fuzzing.
+func newFuzzEtcdServer(t *testing.T, lg *zap.Logger) (*EtcdServer, backend.Backend) {
+	be, _ := betesting.NewDefaultTmpBackend(t)
+	schema.CreateMetaBucket(be.BatchTx())
+
+	cl := newFuzzCluster(lg)
+	ci := cindex.NewConsistentIndex(be)
+
+	// newApplierV3() wraps the backend applier in the auth/quota
+	// appliers, which dereference s.AuthStore()/s.lessor while handling
+	// ordinary Put/Txn requests. Leave those nil here and every fuzzed
+	// request that reaches them crashes before either applier's own
+	// logic ever gets a chance to diverge.
+	authStore := auth.NewAuthStore(lg, schema.NewAuthBackend(lg, be), nil, bcrypt.MinCost)
+	lessor := lease.NewLessor(lg, be, cl, lease.LessorConfig{MinLeaseTTL: int64(5)})
+
+	srv := &EtcdServer{
+		lgMu:         new(sync.RWMutex),
+		lg:           lg,
+		id:           1,
+		r:            *realisticRaftNode(lg),
+		cluster:      cl,
+		w:            wait.New(),
+		consistIndex: ci,
+		beHooks:      serverstorage.NewBackendHooks(lg, ci),
+		authStore:    authStore,
+		lessor:       lessor,
+	}
+	return srv, be
+}
+
+// applyResultsEqual compares the observable parts of two applyResults:
+// the marshaled response proto, the error string, and the number of
+// trace events recorded.
+func applyResultsEqual(a, b *applyResult) bool {
+	if (a == nil) != (b == nil) {
+		return false
+	}
+	if a == nil {
+		return true
+	}
+	aResp, aErr := marshalApplyResponse(a.resp)
+	bResp, bErr := marshalApplyResponse(b.resp)
+	if (aErr == nil) != (bErr == nil) {
+		return false
+	}
+	if aErr == nil && !bytes.Equal(aResp, bResp) {
+		return false
+	}
+	if errString(a.err) != errString(b.err) {
+		return false
+	}
+	return traceStepCount(a.trace) == traceStepCount(b.trace)
+}
+
+func marshalApplyResponse(m proto.Message) ([]byte, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return proto.Marshal(m)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func traceStepCount(tr *traceutil.Trace) int {
+	if tr == nil {
+		return 0
+	}
+	return len(tr.Steps)
+}
+
+// backendStatesEqual compares the meta, members and key buckets of two
+// backends key/value for key/value, using ReadTx().UnsafeRange.
+func backendStatesEqual(a, b backend.Backend) bool {
+	return reflect.DeepEqual(snapshotMembershipBuckets(a), snapshotMembershipBuckets(b)) &&
+		reflect.DeepEqual(snapshotKeyBucket(a), snapshotKeyBucket(b))
+}
+
+func snapshotKeyBucket(be backend.Backend) [][2][]byte {
+	rtx := be.ReadTx()
+	rtx.RLock()
+	defer rtx.RUnlock()
+	keys, vals := rtx.UnsafeRange(schema.Key, []byte{0}, []byte{0xff}, 0)
+	pairs := make([][2][]byte, 0, len(keys))
+	for i := range keys {
+		pairs = append(pairs, [2][]byte{keys[i], vals[i]})
+	}
+	return pairs
+}
+
 func shouldPass(r *pb.InternalRaftRequest, f *fuzz.ConsumeFuzzer) bool {
 	switch {
 	case r.ClusterVersionSet != nil:
@@ -210,3 +320,497 @@ func shouldPass(r *pb.InternalRaftRequest, f *fuzz.ConsumeFuzzer) bool {
 	}
 	return true
 }
+
+// FuzzDowngrade drives InternalRaftRequests that touch the cluster
+// version/downgrade subsystem through a real applierV3Backend backed by
+// a RaftCluster with a bbolt backend, then checks the one invariant this
+// harness can actually observe from outside the raft-apply path: the
+// persisted DowngradeInfo in the meta bucket round-trips (
+// downgradeInfoFromBackend == the last value set through the applier).
+func FuzzDowngrade(data []byte) int {
+	defer catchPanics2()
+
+	f := fuzz.NewConsumer(data)
+
+	t := &testing.T{}
+	lg := zaptest.NewLogger(t)
+
+	be, _ := betesting.NewDefaultTmpBackend(t)
+	defer betesting.Close(t, be)
+	schema.CreateMetaBucket(be.BatchTx())
+
+	cl := membership.NewCluster(lg)
+	cl.SetStore(v2store.New())
+	cl.SetBackend(schema.NewMembershipBackend(lg, be))
+	cl.AddMember(&membership.Member{ID: types.ID(1)}, true)
+
+	ci := cindex.NewConsistentIndex(be)
+	srv := &EtcdServer{
+		lgMu:         new(sync.RWMutex),
+		lg:           lg,
+		id:           1,
+		r:            *realisticRaftNode(lg),
+		cluster:      cl,
+		w:            wait.New(),
+		consistIndex: ci,
+		beHooks:      serverstorage.NewBackendHooks(lg, ci),
+	}
+	srv.applyV3Internal = srv.newApplierV3Internal()
+	dab := srv.newApplierV3Backend()
+
+	number, err := f.GetInt()
+	if err != nil {
+		return 0
+	}
+
+	var lastDowngrade *membership.DowngradeInfo
+	for i := 0; i < number%20; i++ {
+		rr := &pb.InternalRaftRequest{}
+		if err := f.GenerateStruct(rr); err != nil {
+			return 0
+		}
+
+		switch {
+		case rr.ClusterVersionSet != nil, rr.ClusterMemberAttrSet != nil, rr.DowngradeInfoSet != nil:
+		default:
+			continue
+		}
+		if !shouldPass(rr, f) {
+			continue
+		}
+
+		_ = dab.Apply(rr, true)
+
+		if rr.DowngradeInfoSet != nil {
+			lastDowngrade = cl.DowngradeInfo()
+		}
+
+		persisted := schema.DowngradeInfoFromBackend(lg, be)
+		if lastDowngrade != nil && !downgradeInfoEqual(persisted, lastDowngrade) {
+			panic("persisted DowngradeInfo does not round-trip through the meta bucket")
+		}
+
+		// Member-version rejection and the "never more than one minor
+		// above the downgrade target" invariant both live outside this
+		// harness's reach: membership.Attributes has no ServerVersion
+		// field for a member-attribute applier to validate, and
+		// ClusterVersionSet is applied here with no leader-side gating
+		// (that validation happens outside the raft apply path in real
+		// etcd), so nothing prevents a fuzzed Ver from legitimately
+		// exceeding the target. Asserting either here would either test
+		// nothing real or fail on correct apply behavior, so only the
+		// DowngradeInfo round-trip above is checked.
+	}
+	return 1
+}
+
+func downgradeInfoEqual(a, b *membership.DowngradeInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Enabled == b.Enabled && a.TargetVersion == b.TargetVersion
+}
+
+// FuzzApplyV2 tests the v2 store applier path, which Fuzzapply doesn't
+// reach: it builds an applierV2store over a real v2store.Store plus
+// membership cluster and backend, and drives fuzzed pb.Request values
+// through the applierV2 Put/Delete/Post/Sync methods directly; GET and
+// QGET requests read the v2 store directly, and no request goes through
+// EtcdServer.Do(). When the fuzzer picks it, a request is sent to a
+// member-attribute path ("/0/members/<id>/attributes") with its fuzzed
+// Val as the payload, the shape behind the "is not in dotted-tri format"
+// panics whitelisted in catchPanics2; no well-formed member-attribute
+// JSON is built.
+func FuzzApplyV2(data []byte) int {
+	defer catchPanics2()
+
+	f := fuzz.NewConsumer(data)
+
+	t := &testing.T{}
+	lg := zaptest.NewLogger(t)
+
+	st := v2store.New()
+	cl := membership.NewCluster(lg)
+	cl.SetStore(st)
+	cl.AddMember(&membership.Member{ID: types.ID(1)}, true)
+
+	be, _ := betesting.NewDefaultTmpBackend(t)
+	defer betesting.Close(t, be)
+	schema.CreateMetaBucket(be.BatchTx())
+
+	ci := cindex.NewConsistentIndex(be)
+	srv := &EtcdServer{
+		lgMu:         new(sync.RWMutex),
+		lg:           lg,
+		id:           1,
+		r:            *realisticRaftNode(lg),
+		cluster:      cl,
+		w:            wait.New(),
+		consistIndex: ci,
+		beHooks:      serverstorage.NewBackendHooks(lg, ci),
+		v2store:      st,
+	}
+	av2 := newApplierV2(lg, st, cl)
+
+	number, err := f.GetInt()
+	if err != nil {
+		return 0
+	}
+	for i := 0; i < number%20; i++ {
+		path, err := f.GetString()
+		if err != nil {
+			return 0
+		}
+		malformed, err := f.GetBool()
+		if err != nil {
+			return 0
+		}
+		if malformed {
+			// Feed the member-attribute path fuzzed bytes rather
+			// than well-formed member-attribute JSON; this is the
+			// shape that produces the dotted-tri panics.
+			path = fmt.Sprintf("/0/members/%x/attributes", i)
+		}
+
+		r := v2pb.Request{}
+		if err := f.GenerateStruct(&r); err != nil {
+			return 0
+		}
+		r.Path = path
+		r.ID = uint64(i + 1)
+
+		// srv here never had its apply loop started (no s.run(), and
+		// s.w is never Trigger()'d), so routing non-read methods
+		// through srv.Do() would propose through raft and block
+		// forever on s.w.Wait(id). Drive the v2 applier directly
+		// instead, the same way the rest of this harness does.
+		switch r.Method {
+		case "GET", "QGET":
+			_, _ = srv.v2store.Get(r.Path, r.Recursive, r.Sorted)
+		case "PUT":
+			resp, err := av2.Put(r)
+			assertV2Action(r.Method, resp, err)
+		case "DELETE":
+			resp, err := av2.Delete(r)
+			assertV2Action(r.Method, resp, err)
+		case "POST":
+			resp, err := av2.Post(r)
+			assertV2Action(r.Method, resp, err)
+		case "SYNC":
+			av2.Sync(r)
+		}
+	}
+	return 1
+}
+
+// v2MethodActions lists the v2store.Event actions a successful apply of
+// each v2 Request method is allowed to produce. PUT comes back as "set"
+// by default, "update" or "create" when prevExist is set, and
+// "compareAndSwap" when prevValue/prevIndex are given; DELETE comes back
+// as "delete", or "compareAndDelete" when prevValue/prevIndex are given;
+// POST always comes back as "create".
+var v2MethodActions = map[string]map[string]bool{
+	"PUT":    {"set": true, "update": true, "create": true, "compareAndSwap": true},
+	"DELETE": {"delete": true, "compareAndDelete": true},
+	"POST":   {"create": true},
+}
+
+// assertV2Action checks that a successful v2 applier response carries
+// one of the actions production code can actually produce for the given
+// request method, rather than merely checking for crashes.
+func assertV2Action(method string, resp Response, err error) {
+	if err != nil || resp.Event == nil {
+		return
+	}
+	if !v2MethodActions[method][resp.Event.Action] {
+		panic(fmt.Sprintf("%s produced unexpected v2 store action %q", method, resp.Event.Action))
+	}
+}
+
+// FuzzMembershipStorage exercises membership persistence through the
+// MembershipBackend abstraction rather than through applier entries: it
+// replays a fuzzed sequence of AddMember/RemoveMember/UpdateRaftAttributes/
+// UpdateAttributes/SetVersion/SetDowngradeInfo calls against a RaftCluster
+// wired to a real bbolt backend, pushes it to storage, and reconstructs a
+// second cluster on the same backend via Recover(). Members(), Version(),
+// DowngradeInfo() and the removed-member set must match, and trimming the
+// cluster from the backend before a second push must leave the meta and
+// members buckets identical to a fresh push.
+func FuzzMembershipStorage(data []byte) int {
+	defer catchPanics2()
+
+	f := fuzz.NewConsumer(data)
+
+	t := &testing.T{}
+	lg := zaptest.NewLogger(t)
+
+	be, _ := betesting.NewDefaultTmpBackend(t)
+	defer betesting.Close(t, be)
+	schema.CreateMetaBucket(be.BatchTx())
+
+	cl := membership.NewCluster(lg)
+	cl.SetStore(v2store.New())
+	cl.SetBackend(schema.NewMembershipBackend(lg, be))
+	cl.AddMember(&membership.Member{ID: types.ID(1)}, true)
+
+	number, err := f.GetInt()
+	if err != nil {
+		return 0
+	}
+
+	// liveIDs tracks members actually present in cl, so RemoveMember/
+	// UpdateRaftAttributes/UpdateAttributes only ever target a member
+	// that really exists; targeting an id AddMember never produced hits
+	// etcd's mustXFromStore-style helpers, which panic on a missing
+	// store/backend key with a message catchPanics2 doesn't whitelist.
+	liveIDs := []types.ID{types.ID(1)}
+	var removedIDs []types.ID
+	for i := 0; i < number%20; i++ {
+		op, err := f.GetInt()
+		if err != nil {
+			return 0
+		}
+		switch nonNegativeMod(op, 6) {
+		case 0:
+			m := &membership.Member{}
+			if err := f.GenerateStruct(m); err != nil {
+				return 0
+			}
+			cl.AddMember(m, true)
+			liveIDs = append(liveIDs, m.ID)
+		case 1:
+			if len(liveIDs) == 0 {
+				continue
+			}
+			raw, err := f.GetInt()
+			if err != nil {
+				return 0
+			}
+			idx := nonNegativeMod(raw, len(liveIDs))
+			id := liveIDs[idx]
+			cl.RemoveMember(id, true)
+			removedIDs = append(removedIDs, id)
+			liveIDs = append(liveIDs[:idx], liveIDs[idx+1:]...)
+		case 2:
+			if len(liveIDs) == 0 {
+				continue
+			}
+			raw, err := f.GetInt()
+			if err != nil {
+				return 0
+			}
+			id := liveIDs[nonNegativeMod(raw, len(liveIDs))]
+			ra := membership.RaftAttributes{}
+			if err := f.GenerateStruct(&ra); err != nil {
+				return 0
+			}
+			cl.UpdateRaftAttributes(id, ra, true)
+		case 3:
+			if len(liveIDs) == 0 {
+				continue
+			}
+			raw, err := f.GetInt()
+			if err != nil {
+				return 0
+			}
+			id := liveIDs[nonNegativeMod(raw, len(liveIDs))]
+			attr := membership.Attributes{}
+			if err := f.GenerateStruct(&attr); err != nil {
+				return 0
+			}
+			cl.UpdateAttributes(id, attr)
+		case 4:
+			major, err := f.GetInt()
+			if err != nil {
+				return 0
+			}
+			minor, err := f.GetInt()
+			if err != nil {
+				return 0
+			}
+			cl.SetVersion(&semver.Version{Major: int64(major % 10), Minor: int64(minor % 10)}, nil, true)
+		case 5:
+			enabled, err := f.GetBool()
+			if err != nil {
+				return 0
+			}
+			target, err := f.GetString()
+			if err != nil {
+				return 0
+			}
+			cl.SetDowngradeInfo(&membership.DowngradeInfo{Enabled: enabled, TargetVersion: target}, true)
+		}
+	}
+
+	cl.PushMembershipToStorage()
+
+	cl2 := membership.NewCluster(lg)
+	cl2.SetBackend(schema.NewMembershipBackend(lg, be))
+	cl2.Recover(nil)
+
+	if !reflect.DeepEqual(cl.Members(), cl2.Members()) {
+		panic("recovered Members() diverged from the original cluster")
+	}
+	if !reflect.DeepEqual(cl.Version(), cl2.Version()) {
+		panic("recovered Version() diverged from the original cluster")
+	}
+	if !downgradeInfoEqual(cl.DowngradeInfo(), cl2.DowngradeInfo()) {
+		panic("recovered DowngradeInfo() diverged from the original cluster")
+	}
+	for _, id := range removedIDs {
+		if cl.IsIDRemoved(id) != cl2.IsIDRemoved(id) {
+			panic("recovered removed-member set diverged from the original cluster")
+		}
+	}
+
+	before := snapshotMembershipBuckets(be)
+	cl.TrimClusterFromBackend()
+	cl.PushMembershipToStorage()
+	after := snapshotMembershipBuckets(be)
+	if !reflect.DeepEqual(before, after) {
+		panic("TrimClusterFromBackend followed by a push left the meta/members buckets in a different state")
+	}
+
+	return 1
+}
+
+// nonNegativeMod returns v%n folded into [0, n): Go's % preserves the
+// sign of the dividend, so a negative v (f.GetInt() is signed) would
+// otherwise produce a negative or zero index into a slice.
+func nonNegativeMod(v, n int) int {
+	m := v % n
+	if m < 0 {
+		m += n
+	}
+	return m
+}
+
+// snapshotMembershipBuckets reads every key/value pair out of the meta
+// and members buckets so two points in time can be compared byte-for-byte.
+func snapshotMembershipBuckets(be backend.Backend) map[string][][2][]byte {
+	rtx := be.ReadTx()
+	rtx.RLock()
+	defer rtx.RUnlock()
+
+	out := map[string][][2][]byte{}
+	for name, bucket := range map[string]backend.Bucket{
+		"meta":            schema.Meta,
+		"members":         schema.Members,
+		"members_removed": schema.MembersRemoved,
+	} {
+		keys, vals := rtx.UnsafeRange(bucket, []byte{0}, []byte{0xff}, 0)
+		pairs := make([][2][]byte, 0, len(keys))
+		for i := range keys {
+			pairs = append(pairs, [2][]byte{keys[i], vals[i]})
+		}
+		out[name] = pairs
+	}
+	return out
+}
+
+// FuzzConfStatePersistence drives fuzzed raftpb.ConfState values through
+// the BackendHooks pre-commit hook that writes the confState into the meta
+// bucket, then re-opens the backend and checks the stored value decodes
+// byte-for-byte. It also exercises the 3.5 migration path where an older
+// backend has no confState key (must come back as a zero-value ConfState,
+// not panic), and that applying a conf-change entry via (*EtcdServer).apply
+// populates it from scratch.
+func FuzzConfStatePersistence(data []byte) int {
+	defer catchPanics2()
+
+	f := fuzz.NewConsumer(data)
+
+	cs := raftpb.ConfState{}
+	if err := f.GenerateStruct(&cs); err != nil {
+		return 0
+	}
+	consistentIndex, err := f.GetUint64()
+	if err != nil {
+		return 0
+	}
+	term, err := f.GetUint64()
+	if err != nil {
+		return 0
+	}
+
+	t := &testing.T{}
+	lg := zaptest.NewLogger(t)
+
+	be, tmpPath := betesting.NewDefaultTmpBackend(t)
+	schema.CreateMetaBucket(be.BatchTx())
+
+	ci := cindex.NewConsistentIndex(be)
+	ci.SetConsistentIndex(consistentIndex, term)
+	hooks := serverstorage.NewBackendHooks(lg, ci)
+	hooks.SetConfState(&cs)
+
+	tx := be.BatchTx()
+	tx.LockOutsideApply()
+	hooks.OnPreCommitUnsafe(tx)
+	tx.Unlock()
+	be.ForceCommit()
+	betesting.Close(t, be)
+
+	be2 := backend.NewDefaultBackend(lg, tmpPath, nil)
+	defer betesting.Close(t, be2)
+
+	rtx := be2.ReadTx()
+	rtx.RLock()
+	got := schema.UnsafeConfStateFromBackend(lg, rtx)
+	rtx.RUnlock()
+	if got == nil || !reflect.DeepEqual(*got, cs) {
+		panic("ConfState read back from the backend does not match what was written")
+	}
+
+	// A fresh backend with no confState key must report a zero-value
+	// ConfState rather than panicking.
+	be3, _ := betesting.NewDefaultTmpBackend(t)
+	defer betesting.Close(t, be3)
+	schema.CreateMetaBucket(be3.BatchTx())
+
+	rtx3 := be3.ReadTx()
+	rtx3.RLock()
+	empty := schema.UnsafeConfStateFromBackend(lg, rtx3)
+	rtx3.RUnlock()
+	if empty != nil && !reflect.DeepEqual(*empty, raftpb.ConfState{}) {
+		panic("missing confState key did not come back as a zero value")
+	}
+
+	ci3 := cindex.NewConsistentIndex(be3)
+	srv := &EtcdServer{
+		lgMu:         new(sync.RWMutex),
+		lg:           lg,
+		id:           1,
+		r:            *realisticRaftNode(lg),
+		cluster:      newFuzzCluster(lg),
+		w:            wait.New(),
+		consistIndex: ci3,
+		beHooks:      serverstorage.NewBackendHooks(lg, ci3),
+	}
+
+	cc := raftpb.ConfChange{Type: raftpb.ConfChangeAddNode, NodeID: 2}
+	ccData, err := cc.Marshal()
+	if err != nil {
+		return 0
+	}
+	ent := raftpb.Entry{
+		Type:  raftpb.EntryConfChange,
+		Index: 1,
+		Term:  1,
+		Data:  ccData,
+	}
+	appliedConfState := &raftpb.ConfState{}
+	_, _, _ = srv.apply([]raftpb.Entry{ent}, appliedConfState)
+	if reflect.DeepEqual(*appliedConfState, raftpb.ConfState{}) {
+		panic("apply() of a conf-change entry did not populate the ConfState")
+	}
+
+	return 1
+}
+
+func newFuzzCluster(lg *zap.Logger) *membership.RaftCluster {
+	cl := membership.NewCluster(lg)
+	cl.SetStore(v2store.New())
+	cl.AddMember(&membership.Member{ID: types.ID(1)}, true)
+	return cl
+}